@@ -0,0 +1,13 @@
+// Package all registers every database/sql driver sqlrunner knows how to
+// dispatch to. Import it when you want every scheme supported out of the
+// box; import the individual driver subpackages instead to build a
+// slimmer binary.
+package all
+
+import (
+	_ "github.com/wlhtea/sqlrunner/drivers/clickhouse"
+	_ "github.com/wlhtea/sqlrunner/drivers/mssql"
+	_ "github.com/wlhtea/sqlrunner/drivers/mysql"
+	_ "github.com/wlhtea/sqlrunner/drivers/postgres"
+	_ "github.com/wlhtea/sqlrunner/drivers/sqlite3"
+)
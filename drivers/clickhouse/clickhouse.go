@@ -0,0 +1,6 @@
+// Package clickhouse registers the ClickHouse/clickhouse-go database/sql
+// driver. Import it for its side effect to enable clickhouse:// DSNs in
+// sqlrunner.
+package clickhouse
+
+import _ "github.com/ClickHouse/clickhouse-go/v2"
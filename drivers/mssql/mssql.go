@@ -0,0 +1,6 @@
+// Package mssql registers the denisenkom/go-mssqldb database/sql driver
+// under the name "sqlserver". Import it for its side effect to enable
+// mssql:// DSNs in sqlrunner.
+package mssql
+
+import _ "github.com/denisenkom/go-mssqldb"
@@ -0,0 +1,5 @@
+// Package mysql registers the go-sql-driver/mysql database/sql driver.
+// Import it for its side effect to enable mysql:// DSNs in sqlrunner.
+package mysql
+
+import _ "github.com/go-sql-driver/mysql"
@@ -0,0 +1,5 @@
+// Package postgres registers the lib/pq database/sql driver. Import it for
+// its side effect to enable postgres:// DSNs in sqlrunner.
+package postgres
+
+import _ "github.com/lib/pq"
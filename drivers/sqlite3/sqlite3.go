@@ -0,0 +1,5 @@
+// Package sqlite3 registers the mattn/go-sqlite3 database/sql driver.
+// Import it for its side effect to enable sqlite3:// DSNs in sqlrunner.
+package sqlite3
+
+import _ "github.com/mattn/go-sqlite3"
@@ -0,0 +1,268 @@
+package sqlrunner
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// SavedQuery is a stored query definition along with its default
+// parameters.
+type SavedQuery struct {
+	ID     string        `json:"id"`
+	Query  string        `json:"query"`
+	Params []interface{} `json:"params,omitempty"`
+}
+
+// SavedQueryStore persists named SQL queries so they can be executed again
+// by ID without resending the full SQL text.
+type SavedQueryStore interface {
+	Save(query string, params []interface{}) (id string, err error)
+	Load(id string) (*SavedQuery, error)
+}
+
+// SQLSavedQueryStore is a SavedQueryStore backed by a table in the same
+// database the runner queries. Its DDL and bind parameter syntax are
+// chosen per config.Driver, since placeholder style and column types are
+// not portable across database/sql drivers.
+type SQLSavedQueryStore struct {
+	config *DBConfig
+}
+
+// NewSQLSavedQueryStore creates the sqlrunner_saved_queries table if it
+// does not already exist and returns a store backed by it.
+func NewSQLSavedQueryStore(config *DBConfig) (*SQLSavedQueryStore, error) {
+	if _, err := config.db.Exec(savedQueryTableDDL(config.Driver)); err != nil {
+		return nil, fmt.Errorf("failed to create sqlrunner_saved_queries table: %v", err)
+	}
+
+	return &SQLSavedQueryStore{config: config}, nil
+}
+
+// savedQueryTableDDL returns the CREATE TABLE statement for the saved-query
+// table in driver's dialect. MySQL and Postgres need a bounded id column
+// to use it as a primary key, SQL Server needs an existence check it can
+// run as a single batch, and ClickHouse has no PRIMARY KEY / TEXT concept
+// and requires an engine and ORDER BY instead.
+func savedQueryTableDDL(driver string) string {
+	switch driver {
+	case "mysql", "postgres":
+		return `CREATE TABLE IF NOT EXISTS sqlrunner_saved_queries (
+			id VARCHAR(32) PRIMARY KEY,
+			query TEXT NOT NULL,
+			params TEXT
+		)`
+	case "sqlserver":
+		return `IF NOT EXISTS (SELECT * FROM sysobjects WHERE name = 'sqlrunner_saved_queries' AND xtype = 'U')
+			CREATE TABLE sqlrunner_saved_queries (
+				id VARCHAR(32) PRIMARY KEY,
+				query NVARCHAR(MAX) NOT NULL,
+				params NVARCHAR(MAX)
+			)`
+	case "clickhouse":
+		return `CREATE TABLE IF NOT EXISTS sqlrunner_saved_queries (
+			id String,
+			query String,
+			params String
+		) ENGINE = MergeTree() ORDER BY id`
+	default: // sqlite3 and anything else with a TEXT-as-PK-friendly dialect
+		return `CREATE TABLE IF NOT EXISTS sqlrunner_saved_queries (
+			id TEXT PRIMARY KEY,
+			query TEXT NOT NULL,
+			params TEXT
+		)`
+	}
+}
+
+// placeholder returns the bind parameter placeholder for position i
+// (1-based) in driver's syntax: "$1"-style for Postgres, "@p1"-style for
+// SQL Server, and "?" for everything else.
+func placeholder(driver string, i int) string {
+	switch driver {
+	case "postgres":
+		return fmt.Sprintf("$%d", i)
+	case "sqlserver":
+		return fmt.Sprintf("@p%d", i)
+	default:
+		return "?"
+	}
+}
+
+// Save stores query and params under a new random short ID.
+func (s *SQLSavedQueryStore) Save(query string, params []interface{}) (string, error) {
+	id, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal params: %v", err)
+	}
+
+	driver := s.config.Driver
+	insert := fmt.Sprintf(
+		"INSERT INTO sqlrunner_saved_queries (id, query, params) VALUES (%s, %s, %s)",
+		placeholder(driver, 1), placeholder(driver, 2), placeholder(driver, 3),
+	)
+	if _, err := s.config.db.Exec(insert, id, query, string(paramsJSON)); err != nil {
+		return "", fmt.Errorf("failed to save query: %v", err)
+	}
+
+	return id, nil
+}
+
+// Load returns the saved query definition for id.
+func (s *SQLSavedQueryStore) Load(id string) (*SavedQuery, error) {
+	selectQuery := fmt.Sprintf(
+		"SELECT query, params FROM sqlrunner_saved_queries WHERE id = %s",
+		placeholder(s.config.Driver, 1),
+	)
+
+	var query, paramsJSON string
+	err := s.config.db.QueryRow(selectQuery, id).Scan(&query, &paramsJSON)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("saved query %q not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load query: %v", err)
+	}
+
+	var params []interface{}
+	if paramsJSON != "" {
+		if err := json.Unmarshal([]byte(paramsJSON), &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal params: %v", err)
+		}
+	}
+
+	return &SavedQuery{ID: id, Query: query, Params: params}, nil
+}
+
+// randomID returns a short, URL-safe random identifier for a saved query.
+func randomID() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate id: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// savedQueryHandlers registers /save, /load/{id} and /run/{id}, backed by
+// store.
+func savedQueryHandlers(dbConfig *DBConfig, store SavedQueryStore) {
+	http.HandleFunc("/save", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Only POST method is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Query  string        `json:"query"`
+			Params []interface{} `json:"params,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		id, err := store.Save(req.Query, req.Params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	})
+
+	http.HandleFunc("/load/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/load/")
+		if id == "" {
+			http.Error(w, "missing saved query id", http.StatusBadRequest)
+			return
+		}
+
+		sq, err := store.Load(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sq)
+	})
+
+	http.HandleFunc("/run/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Only POST method is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/run/")
+		if id == "" {
+			http.Error(w, "missing saved query id", http.StatusBadRequest)
+			return
+		}
+
+		sq, err := store.Load(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		params, err := runParams(r, sq.Params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		stmt := append([]interface{}{sq.Query}, params...)
+		results, err := dbConfig.ExecuteBatch([][]interface{}{stmt}, false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results[0])
+	})
+}
+
+// runParams resolves the parameters to run a saved query with: a JSON
+// array body takes precedence, falling back to the query string's "param"
+// values, and finally the saved query's own defaults. Body presence is
+// checked by attempting to decode rather than by ContentLength, since
+// chunked-encoded requests report ContentLength == -1.
+func runParams(r *http.Request, defaults []interface{}) ([]interface{}, error) {
+	if r.Body != nil && r.Body != http.NoBody {
+		var override []interface{}
+		switch err := json.NewDecoder(r.Body).Decode(&override); err {
+		case nil:
+			return override, nil
+		case io.EOF:
+			// empty body; fall through to query-string/default params
+		default:
+			return nil, fmt.Errorf("invalid params payload: %v", err)
+		}
+	}
+
+	if values, ok := r.URL.Query()["param"]; ok {
+		params := make([]interface{}, len(values))
+		for i, v := range values {
+			params[i] = v
+		}
+		return params, nil
+	}
+
+	return defaults, nil
+}
@@ -0,0 +1,63 @@
+package sqlrunner
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunParamsBodyOverridesDefaults(t *testing.T) {
+	r := httptest.NewRequest("POST", "/run/abc", strings.NewReader(`["a", 2]`))
+
+	params, err := runParams(r, []interface{}{"default"})
+	if err != nil {
+		t.Fatalf("runParams returned error: %v", err)
+	}
+	if len(params) != 2 || params[0] != "a" || params[1] != float64(2) {
+		t.Errorf("runParams = %v, want [a 2]", params)
+	}
+}
+
+func TestRunParamsQueryStringFallback(t *testing.T) {
+	r := httptest.NewRequest("POST", "/run/abc?param=x&param=y", nil)
+
+	params, err := runParams(r, []interface{}{"default"})
+	if err != nil {
+		t.Fatalf("runParams returned error: %v", err)
+	}
+	if len(params) != 2 || params[0] != "x" || params[1] != "y" {
+		t.Errorf("runParams = %v, want [x y]", params)
+	}
+}
+
+func TestRunParamsDefaultsFallback(t *testing.T) {
+	r := httptest.NewRequest("POST", "/run/abc", nil)
+
+	params, err := runParams(r, []interface{}{"default"})
+	if err != nil {
+		t.Fatalf("runParams returned error: %v", err)
+	}
+	if len(params) != 1 || params[0] != "default" {
+		t.Errorf("runParams = %v, want [default]", params)
+	}
+}
+
+func TestRunParamsEmptyBodyFallsThrough(t *testing.T) {
+	r := httptest.NewRequest("POST", "/run/abc?param=x", strings.NewReader(""))
+
+	params, err := runParams(r, []interface{}{"default"})
+	if err != nil {
+		t.Fatalf("runParams returned error: %v", err)
+	}
+	if len(params) != 1 || params[0] != "x" {
+		t.Errorf("runParams = %v, want [x]", params)
+	}
+}
+
+func TestRunParamsInvalidBody(t *testing.T) {
+	r := httptest.NewRequest("POST", "/run/abc", strings.NewReader(`not json`))
+
+	if _, err := runParams(r, nil); err == nil {
+		t.Error("runParams should reject a malformed JSON body")
+	}
+}
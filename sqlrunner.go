@@ -1,69 +1,229 @@
 package sqlrunner
 
 import (
+	"context"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-
-	_ "github.com/go-sql-driver/mysql"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // DBConfig holds the database configuration
 type DBConfig struct {
-	DSN string // Data Source Name
-}
+	Driver string // database/sql driver name, e.g. "mysql" or "postgres"
+	DSN    string // Data Source Name, in the format Driver expects
 
-// SQLRequest represents a SQL request payload
-type SQLRequest struct {
-	Query string `json:"query"`
-}
+	Policy           *Policy       // allow/deny list of statement keywords
+	MaxRows          int           // 0 means unlimited
+	StatementTimeout time.Duration // 0 means no per-statement timeout
 
-// SQLResponse represents a SQL response payload
-type SQLResponse struct {
-	Result []map[string]interface{} `json:"result"`
-	Error  string                   `json:"error,omitempty"`
+	db *sql.DB // long-lived connection pool, created by Open
 }
 
-// NewDBConfig creates a new DBConfig from environment variables
+// NewDBConfig creates a new DBConfig from environment variables and opens
+// its connection pool. SQL_DSN is parsed as a URL whose scheme (mysql://,
+// postgres://, sqlite3://, mssql://, clickhouse://) selects the
+// database/sql driver to dispatch to; the rest of the URL is translated
+// into the DSN format that driver expects. Import the matching
+// drivers/<name> subpackage (or drivers/all) for the driver to actually be
+// registered.
 func NewDBConfig() (*DBConfig, error) {
-	dsn := os.Getenv("SQL_DSN")
-	if dsn == "" {
+	raw := os.Getenv("SQL_DSN")
+	if raw == "" {
 		return nil, fmt.Errorf("SQL_DSN environment variable not set")
 	}
 
-	return &DBConfig{
-		DSN: dsn,
-	}, nil
+	driver, dsn, err := parseDSN(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SQL_DSN: %v", err)
+	}
+
+	var statementTimeout time.Duration
+	if v := os.Getenv("STATEMENT_TIMEOUT"); v != "" {
+		statementTimeout, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid STATEMENT_TIMEOUT: %v", err)
+		}
+	}
+
+	config := &DBConfig{
+		Driver:           driver,
+		DSN:              dsn,
+		Policy:           NewPolicy(),
+		MaxRows:          envInt("MAX_ROWS", 0),
+		StatementTimeout: statementTimeout,
+	}
+
+	if err := config.Open(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Open creates the underlying connection pool, applies pool lifecycle
+// settings from SQL_MAX_OPEN_CONNS, SQL_MAX_IDLE_CONNS and
+// SQL_CONN_MAX_LIFETIME, and verifies connectivity with a Ping.
+func (config *DBConfig) Open() error {
+	db, err := sql.Open(config.Driver, config.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+
+	if n := envInt("SQL_MAX_OPEN_CONNS", 0); n > 0 {
+		db.SetMaxOpenConns(n)
+	}
+	if n := envInt("SQL_MAX_IDLE_CONNS", 0); n > 0 {
+		db.SetMaxIdleConns(n)
+	}
+	if v := os.Getenv("SQL_CONN_MAX_LIFETIME"); v != "" {
+		lifetime, err := time.ParseDuration(v)
+		if err != nil {
+			db.Close()
+			return fmt.Errorf("invalid SQL_CONN_MAX_LIFETIME: %v", err)
+		}
+		db.SetConnMaxLifetime(lifetime)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to ping database: %v", err)
+	}
+
+	config.db = db
+	return nil
+}
+
+// Close releases the underlying connection pool.
+func (config *DBConfig) Close() error {
+	return config.db.Close()
+}
+
+// Ping verifies that the connection pool can still reach the database.
+func (config *DBConfig) Ping() error {
+	return config.db.Ping()
 }
 
-// ExecuteSQL executes the provided SQL query and returns the results as a JSON-encoded string
-func (config *DBConfig) ExecuteSQL(query string) (string, error) {
-	// Connect to the database
-	db, err := sql.Open("mysql", config.DSN)
+// Stats returns the current connection pool statistics.
+func (config *DBConfig) Stats() sql.DBStats {
+	return config.db.Stats()
+}
+
+// envInt reads key as an integer environment variable, returning def if it
+// is unset or not a valid integer.
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
 	if err != nil {
-		return "", fmt.Errorf("failed to connect to the database: %v", err)
+		return def
 	}
-	defer db.Close()
+	return n
+}
 
-	// Execute the query
-	rows, err := db.Query(query)
+// parseDSN parses a SQL_DSN URL and returns the database/sql driver name to
+// register under, along with the DSN in that driver's native format.
+func parseDSN(raw string) (driver string, dsn string, err error) {
+	u, err := url.Parse(raw)
 	if err != nil {
-		return "", fmt.Errorf("query execution failed: %v", err)
+		return "", "", err
 	}
-	defer rows.Close()
 
-	// Parse the result
+	switch strings.ToLower(u.Scheme) {
+	case "mysql":
+		return "mysql", mysqlDSN(u), nil
+	case "postgres", "postgresql":
+		return "postgres", raw, nil
+	case "sqlite3", "sqlite":
+		return "sqlite3", sqliteDSN(u), nil
+	case "mssql", "sqlserver":
+		return "sqlserver", mssqlDSN(u), nil
+	case "clickhouse":
+		return "clickhouse", raw, nil
+	default:
+		return "", "", fmt.Errorf("unsupported driver scheme %q", u.Scheme)
+	}
+}
+
+// mysqlDSN converts a mysql:// URL into the user:password@tcp(host:port)/dbname
+// form the go-sql-driver/mysql driver expects. It reads the username and
+// password via u.User.Username()/Password(), which net/url has already
+// percent-decoded, rather than u.User.String(), which re-encodes them and
+// would send the literal escaped form as the password.
+func mysqlDSN(u *url.URL) string {
+	var cred string
+	if u.User != nil {
+		username := u.User.Username()
+		password, _ := u.User.Password()
+		cred = username
+		if password != "" {
+			cred += ":" + password
+		}
+		cred += "@"
+	}
+	var query string
+	if u.RawQuery != "" {
+		query = "?" + u.RawQuery
+	}
+	return fmt.Sprintf("%stcp(%s)%s%s", cred, u.Host, u.Path, query)
+}
+
+// sqliteDSN converts a sqlite3:// URL into the file path the
+// mattn/go-sqlite3 driver expects, e.g. sqlite3://./data.db -> ./data.db.
+func sqliteDSN(u *url.URL) string {
+	path := u.Host + u.Path
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path
+}
+
+// mssqlDSN converts a mssql:// URL into the sqlserver:// URL go-mssqldb's
+// msdsn.Parse expects, moving the path segment into a database= query
+// parameter. go-mssqldb treats a URL path segment as a named SQL Server
+// instance rather than a database, so leaving it in the path would
+// silently connect to the login's default database instead of the one
+// requested.
+func mssqlDSN(u *url.URL) string {
+	v := *u
+	v.Scheme = "sqlserver"
+
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		v.Path = ""
+		q := v.Query()
+		q.Set("database", db)
+		v.RawQuery = q.Encode()
+	}
+
+	return v.String()
+}
+
+// scanRows drains rows into a slice of column-name-to-value maps. If
+// maxRows is greater than zero, scanning stops with an error when the
+// result set has more than maxRows rows; exactly maxRows rows is allowed.
+func scanRows(rows *sql.Rows, maxRows int) ([]map[string]interface{}, error) {
 	columns, err := rows.Columns()
 	if err != nil {
-		return "", fmt.Errorf("failed to get columns: %v", err)
+		return nil, fmt.Errorf("failed to get columns: %v", err)
 	}
 
 	result := []map[string]interface{}{}
 
 	for rows.Next() {
+		if maxRows > 0 && len(result) >= maxRows {
+			return nil, fmt.Errorf("result set exceeds MAX_ROWS (%d)", maxRows)
+		}
+
 		columnPointers := make([]interface{}, len(columns))
 		columnData := make([]interface{}, len(columns))
 		for i := range columnPointers {
@@ -71,7 +231,7 @@ func (config *DBConfig) ExecuteSQL(query string) (string, error) {
 		}
 
 		if err := rows.Scan(columnPointers...); err != nil {
-			return "", fmt.Errorf("failed to scan row: %v", err)
+			return nil, fmt.Errorf("failed to scan row: %v", err)
 		}
 
 		rowMap := make(map[string]interface{})
@@ -83,40 +243,553 @@ func (config *DBConfig) ExecuteSQL(query string) (string, error) {
 	}
 
 	if err := rows.Err(); err != nil {
-		return "", fmt.Errorf("error iterating over rows: %v", err)
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
 	}
 
-	// Convert the result to JSON
-	resp := SQLResponse{Result: result}
-	jsonResult, err := json.Marshal(resp)
+	return result, nil
+}
+
+// readKeywords are the leading statement keywords considered read-only.
+var readKeywords = map[string]bool{"SELECT": true, "SHOW": true, "EXPLAIN": true, "WITH": true}
+
+// writeKeywords are statement keywords that modify data or schema. A
+// statement led by WITH is only read-only if none of these appear
+// anywhere in it: a data-modifying CTE such as
+// "WITH x AS (DELETE FROM t RETURNING *) SELECT * FROM x" is led by
+// SELECT/WITH but still writes.
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true,
+	"REPLACE": true, "TRUNCATE": true, "DROP": true, "ALTER": true,
+	"CREATE": true, "GRANT": true, "REVOKE": true, "CALL": true,
+	"EXEC": true, "EXECUTE": true,
+}
+
+// firstKeyword returns the first SQL keyword in query, skipping leading
+// whitespace and -- or /* */ comments, uppercased for comparison against
+// readKeywords and Policy's allow/deny lists.
+func firstKeyword(query string) string {
+	s := strings.TrimSpace(query)
+	for {
+		switch {
+		case strings.HasPrefix(s, "--"):
+			i := strings.IndexByte(s, '\n')
+			if i < 0 {
+				return ""
+			}
+			s = strings.TrimSpace(s[i+1:])
+		case strings.HasPrefix(s, "/*"):
+			i := strings.Index(s, "*/")
+			if i < 0 {
+				return ""
+			}
+			s = strings.TrimSpace(s[i+2:])
+		default:
+			i := 0
+			for i < len(s) && isKeywordByte(s[i]) {
+				i++
+			}
+			return strings.ToUpper(s[:i])
+		}
+	}
+}
+
+func isKeywordByte(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// isIdentByte reports whether b can appear after the first character of
+// an identifier or keyword (letters, digits and underscore).
+func isIdentByte(b byte) bool {
+	return isKeywordByte(b) || (b >= '0' && b <= '9') || b == '_'
+}
+
+// queryKeywords returns every keyword-shaped token in query, uppercased
+// and in order, skipping over -- and /* */ comments and '...'/"..."/`...`
+// quoted literals and identifiers so that keywords mentioned inside a
+// string (e.g. a column named "delete") are not picked up. Tokens are
+// split on identifier boundaries (letters, digits, underscore), not on
+// letters alone, so an identifier like create_date or call_sign is one
+// token (CREATE_DATE, CALL_SIGN) rather than yielding a bare CREATE/CALL.
+func queryKeywords(query string) []string {
+	var keywords []string
+	s := query
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, "--"):
+			i := strings.IndexByte(s, '\n')
+			if i < 0 {
+				return keywords
+			}
+			s = s[i+1:]
+		case strings.HasPrefix(s, "/*"):
+			i := strings.Index(s, "*/")
+			if i < 0 {
+				return keywords
+			}
+			s = s[i+2:]
+		case s[0] == '\'' || s[0] == '"' || s[0] == '`':
+			quote := s[0]
+			i := 1
+			for i < len(s) && s[i] != quote {
+				i++
+			}
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		case isKeywordByte(s[0]):
+			i := 0
+			for i < len(s) && isIdentByte(s[i]) {
+				i++
+			}
+			keywords = append(keywords, strings.ToUpper(s[:i]))
+			s = s[i:]
+		default:
+			s = s[1:]
+		}
+	}
+	return keywords
+}
+
+// isReadOnly reports whether query is safe to run under a read-only
+// policy: its leading keyword must be in readKeywords, and none of its
+// other keywords may be a writeKeywords entry. The full-statement scan
+// (not just the leading keyword) is what catches a write smuggled past a
+// leading SELECT/WITH by a data-modifying CTE or by a second statement
+// in a semicolon-separated batch.
+func isReadOnly(query string) bool {
+	keywords := queryKeywords(query)
+	if len(keywords) == 0 || !readKeywords[keywords[0]] {
+		return false
+	}
+	for _, kw := range keywords[1:] {
+		if writeKeywords[kw] {
+			return false
+		}
+	}
+	return true
+}
+
+// Policy enforces which SQL statements may be executed, based on
+// SQL_READONLY and a configurable allow/deny list of leading keywords.
+type Policy struct {
+	ReadOnly bool
+	Allowed  map[string]bool
+	Denied   map[string]bool
+}
+
+// NewPolicy builds a Policy from SQL_READONLY, SQL_ALLOWED_STATEMENTS and
+// SQL_DENIED_STATEMENTS (comma-separated keyword lists).
+func NewPolicy() *Policy {
+	return &Policy{
+		ReadOnly: os.Getenv("SQL_READONLY") == "true",
+		Allowed:  keywordSet(os.Getenv("SQL_ALLOWED_STATEMENTS")),
+		Denied:   keywordSet(os.Getenv("SQL_DENIED_STATEMENTS")),
+	}
+}
+
+func keywordSet(csv string) map[string]bool {
+	set := map[string]bool{}
+	for _, kw := range strings.Split(csv, ",") {
+		kw = strings.ToUpper(strings.TrimSpace(kw))
+		if kw != "" {
+			set[kw] = true
+		}
+	}
+	return set
+}
+
+// Check returns an error if query is not permitted by the policy.
+func (p *Policy) Check(query string) error {
+	keyword := firstKeyword(query)
+	if keyword == "" {
+		return fmt.Errorf("unable to determine statement type")
+	}
+
+	if p.ReadOnly && !isReadOnly(query) {
+		return fmt.Errorf("statement %q is not allowed in read-only mode", keyword)
+	}
+	if len(p.Allowed) > 0 && !p.Allowed[keyword] {
+		return fmt.Errorf("statement %q is not in SQL_ALLOWED_STATEMENTS", keyword)
+	}
+	if p.Denied[keyword] {
+		return fmt.Errorf("statement %q is denied by SQL_DENIED_STATEMENTS", keyword)
+	}
+	return nil
+}
+
+// statementContext derives a context bound by config.StatementTimeout, if
+// one is configured, along with its cancel function.
+func (config *DBConfig) statementContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if config.StatementTimeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, config.StatementTimeout)
+}
+
+// ExecuteBatch runs a batch of parameterized statements inside a single
+// transaction, committing if all succeed and rolling back on the first
+// error. Each statement is a slice whose first element is the SQL string
+// and remaining elements are positional bind parameters. The returned
+// slice has one entry per statement: a []map[string]interface{} of rows
+// for SELECT-like statements, or a map with "rows_affected" and
+// "last_insert_id" for everything else.
+//
+// If forceReadOnly is true, every statement is checked with isReadOnly
+// regardless of config.Policy; this is how the /query route enforces
+// read-only access independent of the configured policy.
+func (config *DBConfig) ExecuteBatch(statements [][]interface{}, forceReadOnly bool) ([]interface{}, error) {
+	ctx := context.Background()
+	tx, err := config.db.BeginTx(ctx, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal result to JSON: %v", err)
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
 	}
 
-	return string(jsonResult), nil
+	results := make([]interface{}, 0, len(statements))
+	for _, stmt := range statements {
+		if len(stmt) == 0 {
+			tx.Rollback()
+			return nil, fmt.Errorf("empty statement in batch")
+		}
+
+		query, ok := stmt[0].(string)
+		if !ok {
+			tx.Rollback()
+			return nil, fmt.Errorf("first element of statement must be a SQL string")
+		}
+		args := stmt[1:]
+
+		keyword := firstKeyword(query)
+		if forceReadOnly && !isReadOnly(query) {
+			tx.Rollback()
+			return nil, fmt.Errorf("statement %q is not allowed on this endpoint", keyword)
+		}
+		if config.Policy != nil {
+			if err := config.Policy.Check(query); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+
+		stmtCtx, cancel := config.statementContext(ctx)
+
+		if readKeywords[keyword] {
+			rows, err := tx.QueryContext(stmtCtx, query, args...)
+			if err != nil {
+				cancel()
+				tx.Rollback()
+				return nil, fmt.Errorf("query execution failed: %v", err)
+			}
+			rowMaps, err := scanRows(rows, config.MaxRows)
+			rows.Close()
+			cancel()
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			results = append(results, rowMaps)
+		} else {
+			res, err := tx.ExecContext(stmtCtx, query, args...)
+			cancel()
+			if err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("statement execution failed: %v", err)
+			}
+			affected, _ := res.RowsAffected()
+			lastInsertID, _ := res.LastInsertId()
+			results = append(results, map[string]interface{}{
+				"rows_affected":  affected,
+				"last_insert_id": lastInsertID,
+			})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return results, nil
 }
 
-// StartServer starts the HTTP server to listen for SQL requests
-func StartServer(address string) error {
-	dbConfig, err := NewDBConfig()
+// StreamSQL executes a single query and writes its results to w in the
+// given format ("json", "ndjson", or "csv"), encoding each row as it is
+// scanned rather than buffering the full result set in memory. It applies
+// config.Policy, config.MaxRows and config.StatementTimeout the same way
+// ExecuteBatch does, so callers get the same safety guarantees regardless
+// of which path they take.
+func (config *DBConfig) StreamSQL(w io.Writer, format string, query string, args ...interface{}) error {
+	if config.Policy != nil {
+		if err := config.Policy.Check(query); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := config.statementContext(context.Background())
+	defer cancel()
+
+	rows, err := config.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("error creating DB config: %v", err)
+		return fmt.Errorf("query execution failed: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to get columns: %v", err)
+	}
+
+	switch format {
+	case "", "json":
+		err = streamJSON(w, rows, columns, config.MaxRows)
+	case "ndjson":
+		err = streamNDJSON(w, rows, columns, config.MaxRows)
+	case "csv":
+		err = streamCSV(w, rows, columns, config.MaxRows)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	return rows.Err()
+}
+
+// scanRowValues scans the current row into a slice of values, one per
+// column, coerced into a JSON/CSV-friendly form.
+func scanRowValues(rows *sql.Rows, columns []string) ([]interface{}, error) {
+	columnPointers := make([]interface{}, len(columns))
+	columnData := make([]interface{}, len(columns))
+	for i := range columnPointers {
+		columnPointers[i] = &columnData[i]
+	}
+
+	if err := rows.Scan(columnPointers...); err != nil {
+		return nil, fmt.Errorf("failed to scan row: %v", err)
+	}
+
+	for i, v := range columnData {
+		columnData[i] = coerceValue(v)
+	}
+	return columnData, nil
+}
+
+// coerceValue converts a raw driver value into a JSON/CSV-friendly form:
+// []byte becomes string, time.Time becomes RFC3339.
+func coerceValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339)
+	default:
+		return val
+	}
+}
+
+func streamJSON(w io.Writer, rows *sql.Rows, columns []string, maxRows int) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	for n := 0; rows.Next(); n++ {
+		if maxRows > 0 && n >= maxRows {
+			return fmt.Errorf("result set exceeds MAX_ROWS (%d)", maxRows)
+		}
+
+		rowMap, err := scanRowMap(rows, columns)
+		if err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(rowMap)
+		if err != nil {
+			return fmt.Errorf("failed to encode row: %v", err)
+		}
+
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		if _, err := w.Write(encoded); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+func streamNDJSON(w io.Writer, rows *sql.Rows, columns []string, maxRows int) error {
+	for n := 0; rows.Next(); n++ {
+		if maxRows > 0 && n >= maxRows {
+			return fmt.Errorf("result set exceeds MAX_ROWS (%d)", maxRows)
+		}
+
+		rowMap, err := scanRowMap(rows, columns)
+		if err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(rowMap)
+		if err != nil {
+			return fmt.Errorf("failed to encode row: %v", err)
+		}
+
+		if _, err := w.Write(append(encoded, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func streamCSV(w io.Writer, rows *sql.Rows, columns []string, maxRows int) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return fmt.Errorf("failed to write CSV header: %v", err)
+	}
+
+	for n := 0; rows.Next(); n++ {
+		if maxRows > 0 && n >= maxRows {
+			return fmt.Errorf("result set exceeds MAX_ROWS (%d)", maxRows)
+		}
+
+		values, err := scanRowValues(rows, columns)
+		if err != nil {
+			return err
+		}
+
+		record := make([]string, len(values))
+		for i, v := range values {
+			record[i] = csvString(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: %v", err)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// scanRowMap scans the current row into a column-name-to-value map, using
+// the same value coercion as scanRowValues.
+func scanRowMap(rows *sql.Rows, columns []string) (map[string]interface{}, error) {
+	values, err := scanRowValues(rows, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	rowMap := make(map[string]interface{}, len(columns))
+	for i, col := range columns {
+		rowMap[col] = values[i]
+	}
+	return rowMap, nil
+}
+
+// csvString converts a coerced value into its CSV field representation.
+func csvString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return fmt.Sprintf("%v", val)
 	}
+}
+
+// streamWriter wraps an io.Writer and records whether anything has been
+// written to it yet, so a caller that hits an error partway through a
+// stream can tell whether the response body has already been committed.
+type streamWriter struct {
+	io.Writer
+	wrote bool
+}
 
-	http.HandleFunc("/execute", func(w http.ResponseWriter, r *http.Request) {
+func (w *streamWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if n > 0 {
+		w.wrote = true
+	}
+	return n, err
+}
+
+// contentTypeForFormat returns the HTTP Content-Type for a StreamSQL
+// format.
+func contentTypeForFormat(format string) string {
+	switch format {
+	case "ndjson":
+		return "application/x-ndjson"
+	case "csv":
+		return "text/csv"
+	default:
+		return "application/json"
+	}
+}
+
+// batchHandler returns an HTTP handler that decodes a batch request body
+// and executes it via ExecuteBatch, or streams a single statement through
+// StreamSQL when a ndjson/csv format is requested. forceReadOnly is passed
+// through to ExecuteBatch to scope the route to read-only statements
+// regardless of the configured Policy.
+func batchHandler(dbConfig *DBConfig, forceReadOnly bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Only POST method is supported", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var sqlReq SQLRequest
-		if err := json.NewDecoder(r.Body).Decode(&sqlReq); err != nil {
+		var batch [][]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
 			http.Error(w, "Invalid request payload", http.StatusBadRequest)
 			return
 		}
 
-		// Execute the query
-		result, err := dbConfig.ExecuteSQL(sqlReq.Query)
+		// ndjson/csv formats stream a single query directly to the response
+		// instead of buffering the whole batch's results in memory.
+		if format := r.URL.Query().Get("format"); format == "ndjson" || format == "csv" {
+			if len(batch) != 1 || len(batch[0]) == 0 {
+				http.Error(w, "format=ndjson/csv requires a single-statement batch", http.StatusBadRequest)
+				return
+			}
+			query, ok := batch[0][0].(string)
+			if !ok {
+				http.Error(w, "first element of statement must be a SQL string", http.StatusBadRequest)
+				return
+			}
+			if forceReadOnly && !isReadOnly(query) {
+				http.Error(w, "only read statements are allowed on this endpoint", http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("Content-Type", contentTypeForFormat(format))
+			sw := &streamWriter{Writer: w}
+			if err := dbConfig.StreamSQL(sw, format, query, batch[0][1:]...); err != nil {
+				if sw.wrote {
+					// Part of the body is already on the wire, so a
+					// clean HTTP error response is no longer possible;
+					// abort the connection instead of appending
+					// plain-text error to a truncated json/csv body.
+					panic(http.ErrAbortHandler)
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// Execute the batch inside a single transaction
+		results, err := dbConfig.ExecuteBatch(batch, forceReadOnly)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -124,14 +797,47 @@ func StartServer(address string) error {
 
 		// Send the response
 		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(result))
-	})
+		json.NewEncoder(w).Encode(results)
+	}
+}
+
+// StartServer starts the HTTP server to listen for SQL requests
+func StartServer(address string) error {
+	dbConfig, err := NewDBConfig()
+	if err != nil {
+		return fmt.Errorf("error creating DB config: %v", err)
+	}
+
+	// /execute runs batches under the configured Policy; /query forces
+	// read-only regardless of Policy, so callers can scope credentials by
+	// URL.
+	http.HandleFunc("/execute", batchHandler(dbConfig, false))
+	http.HandleFunc("/query", batchHandler(dbConfig, true))
 
 	// 添加新的路由处理 /asdfghjkl
 	http.HandleFunc("/asdfghjkl", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Hello, this is the /asdfghjkl endpoint!"))
 	})
 
+	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if err := dbConfig.Ping(); err != nil {
+			http.Error(w, fmt.Sprintf("database unreachable: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	http.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dbConfig.Stats())
+	})
+
+	savedQueries, err := NewSQLSavedQueryStore(dbConfig)
+	if err != nil {
+		return fmt.Errorf("error creating saved query store: %v", err)
+	}
+	savedQueryHandlers(dbConfig, savedQueries)
+
 	log.Printf("Starting server on %s...", address)
 	return http.ListenAndServe(address, nil)
 }